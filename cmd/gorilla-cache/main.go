@@ -0,0 +1,92 @@
+// Command gorilla-cache is a maintenance tool for the content-addressable
+// download cache that pkg/download.Cache maintains on each managed machine.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/1dustindavis/gorilla/pkg/download"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "prune":
+		err = prune(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gorilla-cache:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: gorilla-cache <command> [flags]
+
+commands:
+  prune     remove cache entries whose contents no longer match their hash
+  verify    check every cache entry against its hash without removing any`)
+}
+
+func prune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dir := fs.String("dir", "", "cache directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+
+	cache := download.NewCache(*dir, 0)
+	removed, err := cache.Prune()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed %d corrupt cache entries\n", removed)
+	return nil
+}
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := fs.String("dir", "", "cache directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		return err
+	}
+
+	bad := 0
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if !download.Verify(*dir+"/"+e.Name(), e.Name()) {
+			fmt.Printf("corrupt: %s\n", e.Name())
+			bad++
+		}
+	}
+
+	fmt.Printf("checked %d entries, %d corrupt\n", len(entries), bad)
+	return nil
+}
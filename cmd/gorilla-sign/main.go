@@ -0,0 +1,186 @@
+// Command gorilla-sign is a repo-maintainer tool for producing the detached
+// signatures that pkg/distsign verifies on the client. It supports
+// generating an offline root key pair, rotating a short-lived signing key
+// (certified by a root), and signing a catalog or package with a signing
+// key.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/1dustindavis/gorilla/pkg/distsign"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "generate-root":
+		err = generateRoot(os.Args[2:])
+	case "rotate-signing-key":
+		err = rotateSigningKey(os.Args[2:])
+	case "sign":
+		err = sign(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gorilla-sign:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: gorilla-sign <command> [flags]
+
+commands:
+  generate-root          generate an offline root Ed25519 key pair
+  rotate-signing-key     certify a new signing key with a root key
+  sign                   sign a catalog or package with a signing key`)
+}
+
+func generateRoot(args []string) error {
+	fs := flag.NewFlagSet("generate-root", flag.ExitOnError)
+	out := fs.String("out", "root", "output file prefix; writes <out>.pub and <out>.key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating root key: %w", err)
+	}
+
+	if err := writeKeyFile(*out+".pub", pub); err != nil {
+		return err
+	}
+	if err := writeKeyFile(*out+".key", priv); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s.pub (distribute/pin in client config) and %s.key (keep offline)\n", *out, *out)
+	return nil
+}
+
+func rotateSigningKey(args []string) error {
+	fs := flag.NewFlagSet("rotate-signing-key", flag.ExitOnError)
+	rootKeyPath := fs.String("root-key", "", "path to the offline root private key")
+	validFor := fs.Duration("valid-for", 30*24*time.Hour, "how long the new signing key should be valid")
+	out := fs.String("out", "signing", "output file prefix; writes <out>.pub, <out>.key, and <out>.cert")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rootKeyPath == "" {
+		return fmt.Errorf("-root-key is required")
+	}
+
+	rootKey, err := readPrivateKeyFile(*rootKeyPath)
+	if err != nil {
+		return fmt.Errorf("reading root key: %w", err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating signing key: %w", err)
+	}
+
+	cert := distsign.NewSigningCert(pub, time.Now().Add(*validFor), rootKey)
+
+	if err := writeKeyFile(*out+".pub", pub); err != nil {
+		return err
+	}
+	if err := writeKeyFile(*out+".key", priv); err != nil {
+		return err
+	}
+
+	certBytes, err := json.MarshalIndent(cert, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding signing cert: %w", err)
+	}
+	if err := ioutil.WriteFile(*out+".cert", certBytes, 0644); err != nil {
+		return fmt.Errorf("writing signing cert: %w", err)
+	}
+
+	fmt.Printf("wrote %s.key (keep with the repo's signing host) and %s.cert (valid until %s)\n", *out, *out, cert.NotAfter)
+	return nil
+}
+
+func sign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	signingKeyPath := fs.String("signing-key", "", "path to the signing private key")
+	certPath := fs.String("cert", "", "path to the signing cert produced by rotate-signing-key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: gorilla-sign sign -signing-key <path> -cert <path> <file>")
+	}
+	file := fs.Arg(0)
+
+	signingKey, err := readPrivateKeyFile(*signingKeyPath)
+	if err != nil {
+		return fmt.Errorf("reading signing key: %w", err)
+	}
+
+	certBytes, err := ioutil.ReadFile(*certPath)
+	if err != nil {
+		return fmt.Errorf("reading signing cert: %w", err)
+	}
+	var cert distsign.SigningCert
+	if err := json.Unmarshal(certBytes, &cert); err != nil {
+		return fmt.Errorf("parsing signing cert: %w", err)
+	}
+
+	sig, err := distsign.SignFile(file, cert, signingKey)
+	if err != nil {
+		return fmt.Errorf("signing %s: %w", file, err)
+	}
+
+	sigBytes, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding signature: %w", err)
+	}
+	if err := ioutil.WriteFile(file+".sig", sigBytes, 0644); err != nil {
+		return fmt.Errorf("writing %s.sig: %w", file, err)
+	}
+
+	fmt.Printf("wrote %s.sig\n", file)
+	return nil
+}
+
+func writeKeyFile(path string, key []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(key)
+	if err := ioutil.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func readPrivateKeyFile(path string) (ed25519.PrivateKey, error) {
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	if len(raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("key has invalid length %d", len(raw))
+	}
+	return ed25519.PrivateKey(raw), nil
+}
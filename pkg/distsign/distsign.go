@@ -0,0 +1,177 @@
+// Package distsign validates that catalogs and packages served to Gorilla
+// were produced by an approved publisher, not merely fetched over TLS.
+//
+// A small set of long-lived "root" Ed25519 keys are pinned in the client
+// config. Roots are kept offline and only ever used to sign short-lived
+// "signing" certificates, which are published alongside the repo and used
+// day-to-day to sign individual catalogs and packages. Every signed file
+// ships with a detached ".sig" file containing the signing certificate and
+// a signature over the file's SHA-256 digest. A compromised repo host (or a
+// MITM holding a valid TLS cert) cannot forge either without the offline
+// root key.
+package distsign
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// SigningCert is a short-lived key, published alongside the repo, that a
+// root key has attested to. It is embedded in every .sig file so verifiers
+// don't need to fetch it separately.
+type SigningCert struct {
+	// PublicKey is the base64-encoded Ed25519 public key being certified.
+	PublicKey string `json:"public_key"`
+	// NotAfter is when this signing key expires and must no longer be trusted.
+	NotAfter time.Time `json:"not_after"`
+	// RootSignature is the root's base64-encoded signature over
+	// "<PublicKey>|<NotAfter as Unix seconds>" (see signedPayload).
+	RootSignature string `json:"root_signature"`
+}
+
+// Signature is the on-disk ".sig" format placed next to a signed catalog or
+// package.
+type Signature struct {
+	Cert SigningCert `json:"cert"`
+	// FileSignature is the base64-encoded signature, made with the signing
+	// key in Cert, over the sha256 digest of the signed file.
+	FileSignature string `json:"file_signature"`
+}
+
+// signedPayload returns the bytes a root key signs to certify a signing key.
+func signedPayload(pubKey string, notAfter time.Time) []byte {
+	return []byte(fmt.Sprintf("%s|%d", pubKey, notAfter.Unix()))
+}
+
+// VerifyCert checks that cert was signed by one of roots and has not expired.
+func VerifyCert(roots []ed25519.PublicKey, cert SigningCert) (ed25519.PublicKey, error) {
+	if time.Now().After(cert.NotAfter) {
+		return nil, fmt.Errorf("signing cert expired at %s", cert.NotAfter)
+	}
+
+	signingKey, err := base64.StdEncoding.DecodeString(cert.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing key: %w", err)
+	}
+	if len(signingKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("signing key has invalid length %d", len(signingKey))
+	}
+
+	rootSig, err := base64.StdEncoding.DecodeString(cert.RootSignature)
+	if err != nil {
+		return nil, fmt.Errorf("decoding root signature: %w", err)
+	}
+
+	payload := signedPayload(cert.PublicKey, cert.NotAfter)
+	for _, root := range roots {
+		if ed25519.Verify(root, payload, rootSig) {
+			return ed25519.PublicKey(signingKey), nil
+		}
+	}
+
+	return nil, fmt.Errorf("signing cert does not chain to any pinned root key")
+}
+
+// VerifyFile checks that sigPath attests to the contents of file, and that
+// the attesting signing cert chains to one of roots.
+func VerifyFile(file string, sigPath string, roots []ed25519.PublicKey) error {
+	sigBytes, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("reading signature: %w", err)
+	}
+
+	var sig Signature
+	if err := json.Unmarshal(sigBytes, &sig); err != nil {
+		return fmt.Errorf("parsing signature: %w", err)
+	}
+
+	signingKey, err := VerifyCert(roots, sig.Cert)
+	if err != nil {
+		return fmt.Errorf("verifying signing cert: %w", err)
+	}
+
+	fileSig, err := base64.StdEncoding.DecodeString(sig.FileSignature)
+	if err != nil {
+		return fmt.Errorf("decoding file signature: %w", err)
+	}
+
+	digest, err := sha256Digest(file)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(signingKey, digest, fileSig) {
+		return fmt.Errorf("signature does not match file contents")
+	}
+
+	return nil
+}
+
+// sha256Digest streams file and returns its SHA-256 digest.
+func sha256Digest(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening file to verify: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("hashing file: %w", err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// ParseRoots decodes a list of base64-encoded Ed25519 public keys, as found
+// in config.TrustedRootKeys.
+func ParseRoots(encoded []string) ([]ed25519.PublicKey, error) {
+	roots := make([]ed25519.PublicKey, 0, len(encoded))
+	for _, e := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(e)
+		if err != nil {
+			return nil, fmt.Errorf("decoding trusted root key: %w", err)
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("trusted root key has invalid length %d", len(raw))
+		}
+		roots = append(roots, ed25519.PublicKey(raw))
+	}
+	return roots, nil
+}
+
+// SignFile signs file's SHA-256 digest with signingKey and wraps the result
+// together with cert into the detached Signature format.
+func SignFile(file string, cert SigningCert, signingKey ed25519.PrivateKey) (Signature, error) {
+	digest, err := sha256Digest(file)
+	if err != nil {
+		return Signature{}, err
+	}
+
+	fileSig := ed25519.Sign(signingKey, digest)
+
+	return Signature{
+		Cert:          cert,
+		FileSignature: base64.StdEncoding.EncodeToString(fileSig),
+	}, nil
+}
+
+// NewSigningCert certifies pubKey as a signing key valid until notAfter,
+// using the offline root private key.
+func NewSigningCert(pubKey ed25519.PublicKey, notAfter time.Time, rootKey ed25519.PrivateKey) SigningCert {
+	encodedPub := base64.StdEncoding.EncodeToString(pubKey)
+	sig := ed25519.Sign(rootKey, signedPayload(encodedPub, notAfter))
+
+	return SigningCert{
+		PublicKey:     encodedPub,
+		NotAfter:      notAfter,
+		RootSignature: base64.StdEncoding.EncodeToString(sig),
+	}
+}
@@ -0,0 +1,110 @@
+package distsign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerifyFileRoundTrip(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signPub, signPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := NewSigningCert(signPub, time.Now().Add(time.Hour), rootPriv)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "catalog.plist")
+	if err := ioutil.WriteFile(file, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := SignFile(file, cert, signPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigBytes, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigPath := file + ".sig"
+	if err := ioutil.WriteFile(sigPath, sigBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyFile(file, sigPath, []ed25519.PublicKey{rootPub}); err != nil {
+		t.Errorf("VerifyFile with correct root should succeed, got: %v", err)
+	}
+
+	otherRoot, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFile(file, sigPath, []ed25519.PublicKey{otherRoot}); err == nil {
+		t.Error("VerifyFile with an unrelated root should fail, got nil error")
+	}
+}
+
+func TestVerifyCertExpired(t *testing.T) {
+	_, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := NewSigningCert(signPub, time.Now().Add(-time.Hour), rootPriv)
+
+	rootPub, err := rootPublicKey(rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyCert([]ed25519.PublicKey{rootPub}, cert); err == nil {
+		t.Error("VerifyCert should reject an expired cert, got nil error")
+	}
+}
+
+// TestVerifyCertRejectsBadSigningKeyLength is a regression test: a
+// malformed or attacker-supplied .sig file must fail closed with an error,
+// not panic inside ed25519.Verify because of a short/long public key.
+func TestVerifyCertRejectsBadSigningKeyLength(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badKey := make([]byte, 10)
+	cert := SigningCert{
+		PublicKey: base64.StdEncoding.EncodeToString(badKey),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	payload := signedPayload(cert.PublicKey, cert.NotAfter)
+	cert.RootSignature = base64.StdEncoding.EncodeToString(ed25519.Sign(rootPriv, payload))
+
+	if _, err := VerifyCert([]ed25519.PublicKey{rootPub}, cert); err == nil {
+		t.Error("VerifyCert should reject a signing key with an invalid length, got nil error")
+	}
+}
+
+func rootPublicKey(priv ed25519.PrivateKey) (ed25519.PublicKey, error) {
+	pub, ok := priv.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+	return pub, nil
+}
@@ -0,0 +1,74 @@
+package download
+
+import (
+	"context"
+	"sync"
+
+	"github.com/1dustindavis/gorilla/pkg/config"
+)
+
+// Item is a single file to fetch as part of a Batch call.
+type Item struct {
+	URL  string
+	Dest string
+	// Size is the item's total size in bytes, if already known (e.g. from
+	// the catalog's declared size). 0 means unknown, in which case reported
+	// progress events carry no ETA.
+	Size int64
+	// SHA is the item's expected SHA-256 digest, if known from the catalog.
+	// When it's set and Batch is given a non-nil cache, the cache is
+	// consulted before touching the network, and a freshly-downloaded item
+	// is stored there for next time.
+	SHA string
+}
+
+// Result is the outcome of downloading one Item.
+type Result struct {
+	Item Item
+	Err  error
+}
+
+// Batch downloads items concurrently, bounded by
+// config.MaxConcurrentDownloads worker goroutines. Every worker goes
+// through FileWithCache, so items served over HTTP share the pooled
+// http.Client that buildClient caches per matching repository. Installs
+// remain the caller's responsibility to serialize; Batch only parallelizes
+// the network transfer. If reporter is non-nil, each item's progress is
+// reported as it downloads. If cache is non-nil, items with a SHA set are
+// served from (and stored into) it, so re-running a batch of
+// already-cached packages never touches the network.
+func Batch(ctx context.Context, items []Item, reporter Reporter, cache *Cache) []Result {
+	workers := config.MaxConcurrentDownloads
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	results := make([]Result, len(items))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := items[i]
+				results[i] = Result{
+					Item: item,
+					Err:  FileWithCache(ctx, item.Dest, item.URL, item.SHA, item.Size, cache, reporter),
+				}
+			}
+		}()
+	}
+
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,174 @@
+package download
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/1dustindavis/gorilla/pkg/gorillalog"
+)
+
+// Cache is a content-addressable store of previously downloaded files,
+// keyed by their SHA-256 digest. FileWithCache consults it before issuing
+// any HTTP request so a package already fetched for one machine (or a
+// previous run) never needs to be downloaded again.
+type Cache struct {
+	Dir string
+	// MaxBytes bounds the cache's total size; once exceeded, the
+	// least-recently-fetched entries are evicted first. <= 0 means
+	// unbounded.
+	MaxBytes int64
+}
+
+// NewCache returns a Cache rooted at dir.
+func NewCache(dir string, maxBytes int64) *Cache {
+	return &Cache{Dir: dir, MaxBytes: maxBytes}
+}
+
+// path returns the on-disk location of the cache entry for sha.
+func (c *Cache) path(sha string) string {
+	return filepath.Join(c.Dir, sha)
+}
+
+// Fetch hardlinks (or, failing that, copies) the cache entry for sha to
+// dest and returns true, if an entry exists and its contents still match
+// sha. A corrupt entry is removed and counted as a miss, so the caller
+// falls back to a real download.
+func (c *Cache) Fetch(dest string, sha string) bool {
+	src := c.path(sha)
+	if _, err := os.Stat(src); err != nil {
+		return false
+	}
+
+	if !Verify(src, sha) {
+		gorillalog.Warn("Removing corrupt cache entry:", sha)
+		os.Remove(src)
+		return false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false
+	}
+	os.Remove(dest)
+
+	if err := os.Link(src, dest); err != nil {
+		// Hardlinking can fail across filesystems/devices; fall back to a copy.
+		if err := copyFile(src, dest); err != nil {
+			return false
+		}
+	}
+
+	// Touch the entry so size-based eviction treats it as recently used.
+	now := time.Now()
+	os.Chtimes(src, now, now)
+
+	return true
+}
+
+// Store atomically places src into the cache under sha, leaving src itself
+// in place, then evicts the
+// least-recently-used entries until the cache is back under MaxBytes. It
+// hardlinks src into place where possible, falling back to a copy (e.g.
+// across filesystems/devices) - the same pattern Fetch already uses in the
+// other direction - so caching a download doesn't read the whole file back
+// just to duplicate it.
+func (c *Cache) Store(src string, sha string) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	dest := c.path(sha)
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+
+	if err := os.Link(src, tmp); err != nil {
+		if err := copyFile(src, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	c.evict()
+	return nil
+}
+
+// evict removes the least-recently-used cache entries until the cache's
+// total size is at or under MaxBytes.
+func (c *Cache) evict() {
+	if c.MaxBytes <= 0 {
+		return
+	}
+
+	entries, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size()
+	}
+
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.Dir, e.Name())); err == nil {
+			total -= e.Size()
+		}
+	}
+}
+
+// Prune removes every cache entry whose contents no longer match the
+// SHA-256 it's keyed by, returning how many were removed.
+func (c *Cache) Prune() (int, error) {
+	entries, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(c.Dir, e.Name())
+		if !Verify(path, e.Name()) {
+			if rmErr := os.Remove(path); rmErr == nil {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,140 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTempFile(t *testing.T, dir string, name string, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func shaOf(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCacheStoreThenFetch(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := t.TempDir()
+	destDir := t.TempDir()
+
+	contents := "a package payload"
+	sha := shaOf(contents)
+	src := writeTempFile(t, srcDir, "pkg.nupkg", contents)
+
+	cache := NewCache(cacheDir, 0)
+	if err := cache.Store(src, sha); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	dest := filepath.Join(destDir, "pkg.nupkg")
+	if !cache.Fetch(dest, sha) {
+		t.Fatal("Fetch should hit after Store, got a miss")
+	}
+
+	got, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != contents {
+		t.Errorf("fetched contents = %q, want %q", got, contents)
+	}
+}
+
+func TestCacheFetchMiss(t *testing.T) {
+	cacheDir := t.TempDir()
+	destDir := t.TempDir()
+
+	cache := NewCache(cacheDir, 0)
+	if cache.Fetch(filepath.Join(destDir, "pkg.nupkg"), shaOf("never stored")) {
+		t.Error("Fetch should miss for a sha that was never stored")
+	}
+}
+
+func TestCacheFetchRemovesCorruptEntry(t *testing.T) {
+	cacheDir := t.TempDir()
+	destDir := t.TempDir()
+
+	sha := shaOf("expected contents")
+	// Plant a cache entry under the right name but with the wrong bytes.
+	writeTempFile(t, cacheDir, sha, "corrupted contents")
+
+	cache := NewCache(cacheDir, 0)
+	if cache.Fetch(filepath.Join(destDir, "pkg.nupkg"), sha) {
+		t.Error("Fetch should miss on a corrupt cache entry")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, sha)); !os.IsNotExist(err) {
+		t.Error("Fetch should remove the corrupt cache entry")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := t.TempDir()
+
+	// Each entry is 10 bytes; cap the cache at 15 so only one fits.
+	cache := NewCache(cacheDir, 15)
+
+	oldContents := "0123456789"
+	oldSha := shaOf(oldContents)
+	oldSrc := writeTempFile(t, srcDir, "old", oldContents)
+	if err := cache.Store(oldSrc, oldSha); err != nil {
+		t.Fatal(err)
+	}
+
+	// Back-date the old entry so it sorts before the new one.
+	old := filepath.Join(cacheDir, oldSha)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(old, past, past)
+
+	newContents := "9876543210"
+	newSha := shaOf(newContents)
+	newSrc := writeTempFile(t, srcDir, "new", newContents)
+	if err := cache.Store(newSrc, newSha); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("oldest entry should have been evicted")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, newSha)); err != nil {
+		t.Error("newest entry should still be present")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	goodContents := "good"
+	goodSha := shaOf(goodContents)
+	writeTempFile(t, cacheDir, goodSha, goodContents)
+
+	badSha := shaOf("expected")
+	writeTempFile(t, cacheDir, badSha, "not what the name claims")
+
+	cache := NewCache(cacheDir, 0)
+	removed, err := cache.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("Prune removed %d entries, want 1", removed)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, goodSha)); err != nil {
+		t.Error("Prune should keep the entry whose contents match its hash")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, badSha)); !os.IsNotExist(err) {
+		t.Error("Prune should remove the entry whose contents don't match its hash")
+	}
+}
@@ -0,0 +1,274 @@
+package download
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1dustindavis/gorilla/pkg/config"
+)
+
+// withTestConfig sets the config knobs fileWithContext reads, runs fn, then
+// restores the previous values so tests don't leak state into each other.
+func withTestConfig(t *testing.T, maxRetries int, initialBackoff time.Duration, resumeDownloads bool, fn func()) {
+	t.Helper()
+
+	origRetries, origBackoff, origResume := config.MaxRetries, config.InitialBackoff, config.ResumeDownloads
+	config.MaxRetries, config.InitialBackoff, config.ResumeDownloads = maxRetries, initialBackoff, resumeDownloads
+	defer func() {
+		config.MaxRetries, config.InitialBackoff, config.ResumeDownloads = origRetries, origBackoff, origResume
+	}()
+
+	fn()
+}
+
+// fakeGetter is a test Getter that fails the first failCount calls with err
+// (after writing failBody, if set), then serves body. It doesn't implement
+// RangeGetter; wrap it in fakeRangeGetter or fakeRangeUnsupportedGetter for
+// that.
+type fakeGetter struct {
+	mu        sync.Mutex
+	body      string
+	failBody  string
+	failCount int
+	err       error
+	calls     int
+	offsets   []int64
+}
+
+func (g *fakeGetter) Get(ctx context.Context, url string, dst io.Writer) (http.Header, error) {
+	return g.serve(dst, 0)
+}
+
+func (g *fakeGetter) serve(dst io.Writer, offset int64) (http.Header, error) {
+	g.mu.Lock()
+	g.calls++
+	g.offsets = append(g.offsets, offset)
+	shouldFail := g.calls <= g.failCount
+	g.mu.Unlock()
+
+	if shouldFail {
+		if g.failBody != "" {
+			io.WriteString(dst, g.failBody)
+		}
+		return nil, g.err
+	}
+
+	body := g.body
+	if offset > 0 && offset <= int64(len(g.body)) {
+		body = g.body[offset:]
+	}
+	_, err := io.WriteString(dst, body)
+	return nil, err
+}
+
+// fakeRangeGetter additionally implements RangeGetter, always honoring the
+// requested offset.
+type fakeRangeGetter struct {
+	fakeGetter
+}
+
+func (g *fakeRangeGetter) GetRange(ctx context.Context, url string, dst io.Writer, offset int64) (http.Header, error) {
+	return g.serve(dst, offset)
+}
+
+// fakeRangeUnsupportedGetter implements RangeGetter but always reports that
+// the remote end ignored the Range request.
+type fakeRangeUnsupportedGetter struct {
+	fakeGetter
+}
+
+func (g *fakeRangeUnsupportedGetter) GetRange(ctx context.Context, url string, dst io.Writer, offset int64) (http.Header, error) {
+	g.mu.Lock()
+	g.calls++
+	g.offsets = append(g.offsets, offset)
+	g.mu.Unlock()
+	return nil, ErrRangeUnsupported
+}
+
+func withFakeGetter(t *testing.T, scheme string, g Getter) {
+	t.Helper()
+	RegisterGetter(scheme, g)
+}
+
+func TestBackoffWithJitter(t *testing.T) {
+	config.InitialBackoff = 100 * time.Millisecond
+	defer func() { config.InitialBackoff = time.Second }()
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		base := config.InitialBackoff << uint(attempt-1)
+		backoff := backoffWithJitter(attempt)
+		if backoff < base || backoff > base+base/2 {
+			t.Errorf("attempt %d: backoffWithJitter() = %s, want in [%s, %s]", attempt, backoff, base, base+base/2)
+		}
+	}
+}
+
+func TestFileWithContextRetriesRetryableErrors(t *testing.T) {
+	withTestConfig(t, 3, time.Millisecond, false, func() {
+		g := &fakeGetter{body: "package contents", failCount: 2, err: &RetryableError{Err: io.ErrClosedPipe}}
+		withFakeGetter(t, "faketest-retry", g)
+
+		dir := t.TempDir()
+		err := FileWithContext(context.Background(), dir, "faketest-retry://host/pkg.nupkg")
+		if err != nil {
+			t.Fatalf("FileWithContext() = %v, want nil after retries succeed", err)
+		}
+
+		got, err := ioutil.ReadFile(filepath.Join(dir, "pkg.nupkg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "package contents" {
+			t.Errorf("downloaded contents = %q, want %q", got, "package contents")
+		}
+		if g.calls != 3 {
+			t.Errorf("getter was called %d times, want 3 (2 failures + 1 success)", g.calls)
+		}
+	})
+}
+
+func TestFileWithContextGivesUpAfterMaxRetries(t *testing.T) {
+	withTestConfig(t, 2, time.Millisecond, false, func() {
+		g := &fakeGetter{failCount: 100, err: &RetryableError{Err: io.ErrClosedPipe}}
+		withFakeGetter(t, "faketest-exhausted", g)
+
+		dir := t.TempDir()
+		err := FileWithContext(context.Background(), dir, "faketest-exhausted://host/pkg.nupkg")
+		if err == nil {
+			t.Fatal("FileWithContext() = nil, want an error once retries are exhausted")
+		}
+		if g.calls != 2 {
+			t.Errorf("getter was called %d times, want 2 (config.MaxRetries)", g.calls)
+		}
+	})
+}
+
+func TestFileWithContextDoesNotRetryNonRetryableError(t *testing.T) {
+	withTestConfig(t, 3, time.Millisecond, false, func() {
+		g := &fakeGetter{failCount: 100, err: io.ErrUnexpectedEOF}
+		withFakeGetter(t, "faketest-nonretryable", g)
+
+		dir := t.TempDir()
+		err := FileWithContext(context.Background(), dir, "faketest-nonretryable://host/pkg.nupkg")
+		if err == nil {
+			t.Fatal("FileWithContext() = nil, want an error")
+		}
+		if g.calls != 1 {
+			t.Errorf("getter was called %d times, want 1 (no retry for a non-retryable error)", g.calls)
+		}
+	})
+}
+
+func TestFileWithContextResumesFromExistingFile(t *testing.T) {
+	withTestConfig(t, 1, time.Millisecond, true, func() {
+		full := "0123456789"
+		g := &fakeRangeGetter{fakeGetter{body: full}}
+		withFakeGetter(t, "faketest-resume", g)
+
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "pkg.nupkg")
+		if err := ioutil.WriteFile(dest, []byte(full[:4]), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := FileWithContext(context.Background(), dir, "faketest-resume://host/pkg.nupkg"); err != nil {
+			t.Fatalf("FileWithContext() = %v", err)
+		}
+
+		got, err := ioutil.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != full {
+			t.Errorf("resumed contents = %q, want %q", got, full)
+		}
+		if len(g.offsets) != 1 || g.offsets[0] != 4 {
+			t.Errorf("GetRange offsets = %v, want [4]", g.offsets)
+		}
+	})
+}
+
+func TestFileWithContextFallsBackWhenRangeUnsupported(t *testing.T) {
+	withTestConfig(t, 1, time.Millisecond, true, func() {
+		full := "0123456789"
+		g := &fakeRangeUnsupportedGetter{fakeGetter{body: full}}
+		withFakeGetter(t, "faketest-range-unsupported", g)
+
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "pkg.nupkg")
+		if err := ioutil.WriteFile(dest, []byte(full[:4]), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := FileWithContext(context.Background(), dir, "faketest-range-unsupported://host/pkg.nupkg"); err != nil {
+			t.Fatalf("FileWithContext() = %v", err)
+		}
+
+		got, err := ioutil.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != full {
+			t.Errorf("restarted contents = %q, want %q (the ignored-range GET should have started over, not appended)", got, full)
+		}
+	})
+}
+
+func TestFileWithContextDoesNotResumeWhenComputingHash(t *testing.T) {
+	withTestConfig(t, 1, time.Millisecond, true, func() {
+		full := "0123456789"
+		g := &fakeRangeGetter{fakeGetter{body: full}}
+		withFakeGetter(t, "faketest-hash-forces-fresh", g)
+
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "pkg.nupkg")
+		if err := ioutil.WriteFile(dest, []byte(full[:4]), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := FileWithCache(context.Background(), dir, "faketest-hash-forces-fresh://host/pkg.nupkg", shaOf(full), 0, nil, nil); err != nil {
+			t.Fatalf("FileWithCache() = %v", err)
+		}
+
+		if len(g.offsets) != 1 || g.offsets[0] != 0 {
+			t.Errorf("getter offsets = %v, want [0] (computeHash must force a fresh download via Get, not GetRange)", g.offsets)
+		}
+	})
+}
+
+func TestProgressTrackerResetOnRetry(t *testing.T) {
+	withTestConfig(t, 2, time.Millisecond, false, func() {
+		// The failed first attempt writes 5 bytes before erroring, so a
+		// tracker that isn't reset would end at 15 instead of 10.
+		g := &fakeGetter{body: "0123456789", failBody: "01234", failCount: 1, err: &RetryableError{Err: io.ErrClosedPipe}}
+		withFakeGetter(t, "faketest-progress-reset", g)
+
+		var events []Event
+		reporter := reporterFunc(func(e Event) { events = append(events, e) })
+
+		dir := t.TempDir()
+		if err := FileWithProgress(context.Background(), dir, "faketest-progress-reset://host/pkg.nupkg", 10, reporter); err != nil {
+			t.Fatalf("FileWithProgress() = %v", err)
+		}
+
+		final := events[len(events)-1]
+		if !final.Done {
+			t.Fatalf("last event = %+v, want Done", final)
+		}
+		if final.BytesRead != int64(len("0123456789")) {
+			t.Errorf("final BytesRead = %d, want %d (a retried attempt must not double-count the discarded one)", final.BytesRead, len("0123456789"))
+		}
+	})
+}
+
+// reporterFunc adapts a plain func into a Reporter, for tests that only
+// care about capturing events rather than building a dedicated type.
+type reporterFunc func(Event)
+
+func (f reporterFunc) Report(e Event) { f(e) }
@@ -0,0 +1,95 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Getter fetches the contents of url and streams them into dst, returning
+// whatever response headers are worth inspecting (Content-Length, ETag, and
+// so on). Implementations are registered against a URL scheme, mirroring
+// the plugin-getter pattern used by chart repositories, so that `catalog`,
+// `manifest`, and `process` never need to know whether a given URL is
+// served over HTTP, sits on a local/SMB-mounted path, or lives in a cloud
+// object store.
+type Getter interface {
+	Get(ctx context.Context, url string, dst io.Writer) (http.Header, error)
+}
+
+// RangeGetter is implemented by Getters that can resume a partial transfer
+// starting at a byte offset. Schemes without a meaningful "partial object"
+// concept (file://, and most object stores) don't need to implement it;
+// FileWithContext falls back to a full re-download when a Getter doesn't
+// satisfy this interface.
+type RangeGetter interface {
+	GetRange(ctx context.Context, url string, dst io.Writer, offset int64) (http.Header, error)
+}
+
+// ErrRangeUnsupported is returned by a RangeGetter when the remote end
+// ignored the requested byte offset and is sending the object from the
+// start.
+var ErrRangeUnsupported = fmt.Errorf("remote end does not support ranged requests")
+
+// RetryableError wraps an error from a Getter that is worth retrying, such
+// as a network failure, HTTP 429, or HTTP 5xx.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Getter{}
+)
+
+// RegisterGetter installs g as the Getter responsible for urls with the
+// given scheme (e.g. "s3", "gs", "file"). Registering a scheme that is
+// already installed replaces it, which is mainly useful for tests.
+func RegisterGetter(scheme string, g Getter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[scheme] = g
+}
+
+// getterFor returns the Getter registered for rawURL's scheme. A URL with
+// no scheme (a plain filesystem path) is treated as "file".
+func getterFor(rawURL string) (Getter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url %q: %w", rawURL, err)
+	}
+
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "file"
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	g, ok := registry[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no getter registered for scheme %q", scheme)
+	}
+	return g, nil
+}
+
+func init() {
+	httpGetter := &HTTPGetter{}
+	RegisterGetter("http", httpGetter)
+	RegisterGetter("https", httpGetter)
+	RegisterGetter("file", &FileGetter{})
+	RegisterGetter("s3", &S3Getter{})
+	RegisterGetter("gs", &GSGetter{})
+	RegisterGetter("azure", &AzureGetter{})
+}
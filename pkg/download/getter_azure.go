@@ -0,0 +1,68 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// AzureGetter is the built-in Getter for azure:// urls, of the form
+// azure://<account>.blob.core.windows.net/<container>/<blob>. It
+// authenticates with DefaultAzureCredential rather than requiring a
+// fronting HTTP server.
+type AzureGetter struct{}
+
+// Get implements Getter.
+func (g *AzureGetter) Get(ctx context.Context, rawURL string, dst io.Writer) (http.Header, error) {
+	accountURL, container, blob, err := parseAzureURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating Azure credential: %w", err)
+	}
+
+	client, err := azblob.NewClient(accountURL, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating Azure blob client: %w", err)
+	}
+
+	resp, err := client.DownloadStream(ctx, container, blob, nil)
+	if err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+
+	return nil, nil
+}
+
+// parseAzureURL splits an azure://<account>.blob.core.windows.net/<container>/<blob>
+// url into the account's base URL, container, and blob name.
+func parseAzureURL(rawURL string) (accountURL string, container string, blob string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("parsing url %q: %w", rawURL, err)
+	}
+	if u.Scheme != "azure" {
+		return "", "", "", fmt.Errorf("not an azure:// url: %q", rawURL)
+	}
+
+	accountURL = "https://" + u.Host
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("azure url must be azure://<account>/<container>/<blob>: %q", rawURL)
+	}
+	return accountURL, parts[0], parts[1], nil
+}
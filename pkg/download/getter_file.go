@@ -0,0 +1,44 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+)
+
+// FileGetter is the built-in Getter for file:// urls (and bare filesystem
+// paths), for air-gapped setups or catalogs served off an SMB/NFS mount
+// rather than an HTTP endpoint.
+type FileGetter struct{}
+
+// driveLetterPath matches the leading "/C:/..." that url.Parse leaves on a
+// file:///C:/path url's Path - a valid absolute path on every other OS, but
+// not on Windows, where it must be stripped down to "C:/...".
+var driveLetterPath = regexp.MustCompile(`^/[a-zA-Z]:[/\\]`)
+
+// Get implements Getter.
+func (g *FileGetter) Get(ctx context.Context, rawURL string, dst io.Writer) (http.Header, error) {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme == "file" {
+		path = u.Path
+		if driveLetterPath.MatchString(path) {
+			path = path[1:]
+		}
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer src.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
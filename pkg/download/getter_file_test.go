@@ -0,0 +1,73 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileGetterGet(t *testing.T) {
+	dir := t.TempDir()
+	contents := "catalog contents"
+	path := filepath.Join(dir, "catalog.yaml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "posix file url", url: "file://" + path},
+		{name: "bare filesystem path", url: path},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &FileGetter{}
+			var buf bytes.Buffer
+			if _, err := g.Get(context.Background(), tt.url, &buf); err != nil {
+				t.Fatalf("Get(%q): %v", tt.url, err)
+			}
+			if buf.String() != contents {
+				t.Errorf("Get(%q) = %q, want %q", tt.url, buf.String(), contents)
+			}
+		})
+	}
+}
+
+func TestFileGetterGetMissingFile(t *testing.T) {
+	g := &FileGetter{}
+	var buf bytes.Buffer
+	if _, err := g.Get(context.Background(), "file:///no/such/file", &buf); err == nil {
+		t.Fatal("expected an error opening a missing file, got nil")
+	}
+}
+
+// TestDriveLetterPathStripping checks which paths the driveLetterPath
+// pattern matches: the Path of a Windows file:// url (leading slash before
+// the drive letter must be stripped, with either separator), a POSIX
+// absolute path (already valid, must be left alone), and a relative bare
+// filesystem path (no leading slash, so the pattern does not match it).
+func TestDriveLetterPathStripping(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		wantStrip bool
+	}{
+		{name: "windows drive letter with forward slash", path: "/C:/ProgramData/gorilla/catalog.yaml", wantStrip: true},
+		{name: "windows drive letter with backslash", path: `/d:\pkgs\foo.nupkg`, wantStrip: true},
+		{name: "posix absolute path", path: "/home/gorilla/catalog.yaml", wantStrip: false},
+		{name: "bare filesystem path", path: "catalog.yaml", wantStrip: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := driveLetterPath.MatchString(tt.path); got != tt.wantStrip {
+				t.Errorf("driveLetterPath.MatchString(%q) = %v, want %v", tt.path, got, tt.wantStrip)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// GSGetter is the built-in Getter for gs:// urls, authenticating with
+// Application Default Credentials rather than requiring a fronting HTTP
+// server.
+type GSGetter struct{}
+
+// Get implements Getter.
+func (g *GSGetter) Get(ctx context.Context, rawURL string, dst io.Writer) (http.Header, error) {
+	bucket, object, err := parseGSURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCS client: %w", err)
+	}
+	defer client.Close()
+
+	r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+	defer r.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+
+	return nil, nil
+}
+
+// parseGSURL splits a gs://bucket/object url into its bucket and object name.
+func parseGSURL(rawURL string) (bucket string, object string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing url %q: %w", rawURL, err)
+	}
+	if u.Scheme != "gs" {
+		return "", "", fmt.Errorf("not a gs:// url: %q", rawURL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
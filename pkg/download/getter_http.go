@@ -0,0 +1,66 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPGetter is the built-in Getter for http:// and https:// urls. It
+// carries over the TLS client-cert and basic-auth configuration that
+// download.File has always supported.
+type HTTPGetter struct{}
+
+// Get implements Getter.
+func (g *HTTPGetter) Get(ctx context.Context, url string, dst io.Writer) (http.Header, error) {
+	return g.get(ctx, url, dst, 0)
+}
+
+// GetRange implements RangeGetter.
+func (g *HTTPGetter) GetRange(ctx context.Context, url string, dst io.Writer, offset int64) (http.Header, error) {
+	return g.get(ctx, url, dst, offset)
+}
+
+func (g *HTTPGetter) get(ctx context.Context, url string, dst io.Writer, offset int64) (http.Header, error) {
+	client := buildClient(url)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	if user, pass := basicAuthFor(url); user != "" && pass != "" {
+		req.SetBasicAuth(user, pass)
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusOK:
+		return resp.Header, ErrRangeUnsupported
+	case resp.StatusCode == http.StatusPartialContent:
+		// Server honored our Range request.
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// Plain 2XX.
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return resp.Header, &RetryableError{Err: fmt.Errorf("download status code: %d", resp.StatusCode)}
+	default:
+		return resp.Header, fmt.Errorf("download status code: %d", resp.StatusCode)
+	}
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return resp.Header, &RetryableError{Err: err}
+	}
+
+	return resp.Header, nil
+}
@@ -0,0 +1,61 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Getter is the built-in Getter for s3:// urls. It authenticates using
+// the standard AWS SDK credential chain (environment, shared config,
+// instance/task role) rather than requiring a fronting HTTP server, so a
+// private bucket can be used directly as a catalog_url or pkg_repo_url.
+type S3Getter struct{}
+
+// Get implements Getter.
+func (g *S3Getter) Get(ctx context.Context, rawURL string, dst io.Writer) (http.Header, error) {
+	bucket, key, err := parseS3URL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS credentials: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg)
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+	defer out.Body.Close()
+
+	if _, err := io.Copy(dst, out.Body); err != nil {
+		return nil, &RetryableError{Err: err}
+	}
+
+	return nil, nil
+}
+
+// parseS3URL splits an s3://bucket/key url into its bucket and key.
+func parseS3URL(rawURL string) (bucket string, key string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing url %q: %w", rawURL, err)
+	}
+	if u.Scheme != "s3" {
+		return "", "", fmt.Errorf("not an s3:// url: %q", rawURL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
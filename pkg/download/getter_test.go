@@ -0,0 +1,59 @@
+package download
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type stubGetter struct{}
+
+func (stubGetter) Get(ctx context.Context, url string, dst io.Writer) (http.Header, error) {
+	return nil, nil
+}
+
+func TestGetterForDispatchesByScheme(t *testing.T) {
+	g := stubGetter{}
+	RegisterGetter("gettertest-scheme", g)
+
+	got, err := getterFor("gettertest-scheme://host/pkg.nupkg")
+	if err != nil {
+		t.Fatalf("getterFor() = %v", err)
+	}
+	if got != Getter(g) {
+		t.Errorf("getterFor() = %v, want the registered getter", got)
+	}
+}
+
+func TestGetterForDefaultsBarePathToFile(t *testing.T) {
+	got, err := getterFor("/var/catalogs/catalog.yaml")
+	if err != nil {
+		t.Fatalf("getterFor() = %v", err)
+	}
+	if _, ok := got.(*FileGetter); !ok {
+		t.Errorf("getterFor(bare path) = %T, want *FileGetter", got)
+	}
+}
+
+func TestGetterForUnknownScheme(t *testing.T) {
+	if _, err := getterFor("ftp://host/catalog.yaml"); err == nil {
+		t.Fatal("getterFor() = nil error, want an error for an unregistered scheme")
+	}
+}
+
+func TestRegisterGetterReplacesExisting(t *testing.T) {
+	first := stubGetter{}
+	RegisterGetter("gettertest-replace", first)
+
+	second := &fakeGetter{}
+	RegisterGetter("gettertest-replace", second)
+
+	got, err := getterFor("gettertest-replace://host/pkg.nupkg")
+	if err != nil {
+		t.Fatalf("getterFor() = %v", err)
+	}
+	if got != Getter(second) {
+		t.Errorf("getterFor() = %v, want the most recently registered getter", got)
+	}
+}
@@ -0,0 +1,144 @@
+package download
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event describes incremental progress on a single download.
+type Event struct {
+	URL       string        `json:"url"`
+	BytesRead int64         `json:"bytes_read"`
+	Total     int64         `json:"total,omitempty"` // 0 if unknown
+	ETA       time.Duration `json:"eta,omitempty"`   // 0 if unknown
+	Done      bool          `json:"done"`
+	Err       string        `json:"err,omitempty"`
+}
+
+// Reporter receives progress events as downloads proceed, so a caller (for
+// example gorilla invoked from an MDM) can stream progress back to wherever
+// it's watching instead of only learning the final pass/fail result.
+type Reporter interface {
+	Report(Event)
+}
+
+// StderrReporter writes a single human-readable progress line per event to
+// os.Stderr. Callers pass it explicitly; a nil Reporter disables progress
+// reporting rather than falling back to this one.
+type StderrReporter struct{}
+
+// Report implements Reporter.
+func (StderrReporter) Report(e Event) {
+	if e.Err != "" {
+		fmt.Fprintf(os.Stderr, "%s: failed: %s\n", e.URL, e.Err)
+		return
+	}
+	if e.Done {
+		fmt.Fprintf(os.Stderr, "%s: done (%d bytes)\n", e.URL, e.BytesRead)
+		return
+	}
+	if e.Total > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d/%d bytes, eta %s\n", e.URL, e.BytesRead, e.Total, e.ETA.Round(time.Second))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d bytes\n", e.URL, e.BytesRead)
+}
+
+// JSONReporter writes each Event as a single line of JSON to w, so a parent
+// process (an MDM agent wrapping gorilla) can parse progress without
+// screen-scraping human-readable text.
+type JSONReporter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONReporter returns a JSONReporter that writes to w.
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{w: w}
+}
+
+// Report implements Reporter.
+func (r *JSONReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	r.w.Write(append(line, '\n'))
+}
+
+// progressWriter wraps an io.Writer and invokes onBytes with the number of
+// bytes written on every successful Write, so callers can observe a
+// download's progress without Getter implementations knowing anything about
+// Reporter.
+type progressWriter struct {
+	w       io.Writer
+	onBytes func(int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 {
+		p.onBytes(int64(n))
+	}
+	return n, err
+}
+
+// progressTracker accumulates bytes written for a single download and
+// reports Events to a Reporter, computing an ETA from the observed transfer
+// rate when the total size is known.
+type progressTracker struct {
+	url       string
+	total     int64
+	reporter  Reporter
+	start     time.Time
+	mu        sync.Mutex
+	bytesRead int64
+}
+
+func newProgressTracker(url string, total int64, reporter Reporter) *progressTracker {
+	return &progressTracker{url: url, total: total, reporter: reporter, start: time.Now()}
+}
+
+// reset seeds the tracker's running byte count at the start of a new
+// download attempt, to bytesRead bytes already on disk (0 for an attempt
+// starting from scratch). Without this, a retried attempt that restarts
+// from byte zero would keep counting on top of whatever an earlier,
+// discarded attempt had already reported, double-counting bytes and
+// letting the final Done event report more bytes than the file actually
+// contains.
+func (t *progressTracker) reset(bytesRead int64) {
+	t.mu.Lock()
+	t.bytesRead = bytesRead
+	t.mu.Unlock()
+}
+
+func (t *progressTracker) onBytes(n int64) {
+	t.mu.Lock()
+	t.bytesRead += n
+	bytesRead := t.bytesRead
+	t.mu.Unlock()
+
+	event := Event{URL: t.url, BytesRead: bytesRead, Total: t.total}
+	if t.total > 0 {
+		if rate := float64(bytesRead) / time.Since(t.start).Seconds(); rate > 0 {
+			remaining := t.total - bytesRead
+			event.ETA = time.Duration(float64(remaining)/rate) * time.Second
+		}
+	}
+	t.reporter.Report(event)
+}
+
+func (t *progressTracker) done(err error) {
+	event := Event{URL: t.url, BytesRead: t.bytesRead, Total: t.total, Done: true}
+	if err != nil {
+		event.Err = err.Error()
+	}
+	t.reporter.Report(event)
+}
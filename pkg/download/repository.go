@@ -0,0 +1,32 @@
+package download
+
+import (
+	"strings"
+
+	"github.com/1dustindavis/gorilla/pkg/config"
+)
+
+// matchRepositoryConfig returns the entry in config.Repositories (a list of
+// config.RepositoryConfig{URLPrefix, CertFile, KeyFile, CAFile, Username,
+// Password, InsecureSkipVerify}) whose URLPrefix is the longest match for
+// url, and whether any entry matched at all. This lets a single run pull
+// the base catalog from an internal mTLS repo while fetching third-party
+// packages from a public CDN (or a vendor's basic-auth repo), instead of
+// forcing every url to share one global set of credentials. When multiple
+// entries match, the most specific (longest) prefix wins.
+func matchRepositoryConfig(url string) (config.RepositoryConfig, bool) {
+	var best config.RepositoryConfig
+	var matched bool
+
+	for _, repo := range config.Repositories {
+		if !strings.HasPrefix(url, repo.URLPrefix) {
+			continue
+		}
+		if !matched || len(repo.URLPrefix) > len(best.URLPrefix) {
+			best = repo
+			matched = true
+		}
+	}
+
+	return best, matched
+}
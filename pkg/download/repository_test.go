@@ -0,0 +1,39 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/1dustindavis/gorilla/pkg/config"
+)
+
+func TestMatchRepositoryConfigLongestPrefixWins(t *testing.T) {
+	orig := config.Repositories
+	defer func() { config.Repositories = orig }()
+
+	config.Repositories = []config.RepositoryConfig{
+		{URLPrefix: "https://repo.example.com/", Username: "general"},
+		{URLPrefix: "https://repo.example.com/packages/", Username: "packages"},
+	}
+
+	repo, ok := matchRepositoryConfig("https://repo.example.com/packages/pkg.nupkg")
+	if !ok {
+		t.Fatal("matchRepositoryConfig() matched = false, want true")
+	}
+	if repo.Username != "packages" {
+		t.Errorf("matchRepositoryConfig() = %+v, want the longer (more specific) prefix to win", repo)
+	}
+}
+
+func TestMatchRepositoryConfigNoMatch(t *testing.T) {
+	orig := config.Repositories
+	defer func() { config.Repositories = orig }()
+
+	config.Repositories = []config.RepositoryConfig{
+		{URLPrefix: "https://repo.example.com/"},
+	}
+
+	_, ok := matchRepositoryConfig("https://other.example.com/catalog.yaml")
+	if ok {
+		t.Error("matchRepositoryConfig() matched = true, want false for a url with no matching prefix")
+	}
+}